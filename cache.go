@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	cveCacheBucket     = []byte("cve")
+	releaseCacheBucket = []byte("release")
+	feedCacheBucket    = []byte("feed")
+)
+
+// cveCache caches the results of CVE and release lookups on disk, so a
+// restart or a flaky upstream doesn't force every CVE and release in the
+// release notes to be re-fetched.
+type cveCache interface {
+	GetCVE(id string) (cve, bool)
+	PutCVE(id string, found cve) error
+	GetRelease(version string) (coreOSRelease, bool)
+	PutRelease(version string, release coreOSRelease) error
+	GetFeed(key string) (map[string]interface{}, bool)
+	PutFeed(key string, feed map[string]interface{}) error
+}
+
+// cacheEntry wraps a cached value with the time it was fetched, so lookups
+// can apply a TTL.
+type cacheEntry struct {
+	FetchedAt time.Time              `json:"fetchedAt"`
+	CVE       *cve                   `json:"cve,omitempty"`
+	Release   *coreOSRelease         `json:"release,omitempty"`
+	Feed      map[string]interface{} `json:"feed,omitempty"`
+}
+
+// boltCache is a cveCache backed by a BoltDB file on disk.
+type boltCache struct {
+	db  *bolt.DB
+	ttl time.Duration
+}
+
+func newBoltCache(cacheDir string, ttl time.Duration) (*boltCache, error) {
+	db, err := bolt.Open(filepath.Join(cacheDir, "coreos-version-checker.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(cveCacheBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(releaseCacheBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(feedCacheBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltCache{db: db, ttl: ttl}, nil
+}
+
+func (c *boltCache) GetCVE(id string) (cve, bool) {
+	entry, ok := c.get(cveCacheBucket, id)
+	if !ok || entry.CVE == nil {
+		cacheMisses.WithLabelValues("cve").Inc()
+		return cve{}, false
+	}
+
+	cacheHits.WithLabelValues("cve").Inc()
+	return *entry.CVE, true
+}
+
+func (c *boltCache) PutCVE(id string, found cve) error {
+	return c.put(cveCacheBucket, id, cacheEntry{FetchedAt: time.Now(), CVE: &found})
+}
+
+func (c *boltCache) GetRelease(version string) (coreOSRelease, bool) {
+	entry, ok := c.get(releaseCacheBucket, version)
+	if !ok || entry.Release == nil {
+		cacheMisses.WithLabelValues("release").Inc()
+		return coreOSRelease{}, false
+	}
+
+	cacheHits.WithLabelValues("release").Inc()
+	return *entry.Release, true
+}
+
+func (c *boltCache) PutRelease(version string, release coreOSRelease) error {
+	return c.put(releaseCacheBucket, version, cacheEntry{FetchedAt: time.Now(), Release: &release})
+}
+
+// GetFeed and PutFeed cache a distribution's raw releases.json-shaped feed
+// per channel, so computing drift alongside a release lookup doesn't re-fetch
+// the whole feed a second time within the TTL.
+func (c *boltCache) GetFeed(key string) (map[string]interface{}, bool) {
+	entry, ok := c.get(feedCacheBucket, key)
+	if !ok || entry.Feed == nil {
+		cacheMisses.WithLabelValues("feed").Inc()
+		return nil, false
+	}
+
+	cacheHits.WithLabelValues("feed").Inc()
+	return entry.Feed, true
+}
+
+func (c *boltCache) PutFeed(key string, feed map[string]interface{}) error {
+	return c.put(feedCacheBucket, key, cacheEntry{FetchedAt: time.Now(), Feed: feed})
+}
+
+func (c *boltCache) get(bucket []byte, key string) (cacheEntry, bool) {
+	var entry cacheEntry
+	found := false
+
+	c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil
+		}
+		found = true
+		return nil
+	})
+
+	if !found || time.Since(entry.FetchedAt) > c.ttl {
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (c *boltCache) put(bucket []byte, key string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), data)
+	})
+}
+
+func (c *boltCache) Close() error {
+	return c.db.Close()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoltCacheTTLExpiry(t *testing.T) {
+	cache, err := newBoltCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("newBoltCache: %v", err)
+	}
+	defer cache.Close()
+
+	if err := cache.PutCVE("CVE-2024-1", cve{ID: "CVE-2024-1", CVSS: 9.8}); err != nil {
+		t.Fatalf("PutCVE: %v", err)
+	}
+
+	if _, ok := cache.GetCVE("CVE-2024-1"); !ok {
+		t.Fatal("expected a fresh entry to be a cache hit")
+	}
+
+	expired := cacheEntry{FetchedAt: time.Now().Add(-2 * time.Hour), CVE: &cve{ID: "CVE-2024-1", CVSS: 9.8}}
+	if err := cache.put(cveCacheBucket, "CVE-2024-1", expired); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if _, ok := cache.GetCVE("CVE-2024-1"); ok {
+		t.Fatal("expected an entry past its TTL to be a cache miss")
+	}
+}
+
+func TestBoltCacheFeedRoundTrip(t *testing.T) {
+	cache, err := newBoltCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("newBoltCache: %v", err)
+	}
+	defer cache.Close()
+
+	if _, ok := cache.GetFeed("stable"); ok {
+		t.Fatal("expected a miss before anything was cached")
+	}
+
+	feed := map[string]interface{}{"1.2.3": map[string]interface{}{"release_notes": "notes"}}
+	if err := cache.PutFeed("stable", feed); err != nil {
+		t.Fatalf("PutFeed: %v", err)
+	}
+
+	got, ok := cache.GetFeed("stable")
+	if !ok {
+		t.Fatal("expected a hit after PutFeed")
+	}
+	if _, ok := got["1.2.3"]; !ok {
+		t.Errorf("cached feed missing expected release, got %v", got)
+	}
+}
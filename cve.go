@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	circlCveUri string = "http://cve.circl.lu/api/cve/%s"
+	nvdCveUri   string = "https://services.nvd.nist.gov/rest/json/cves/2.0?cveId=%s"
+)
+
+// cveSource looks up vulnerability metadata for a single CVE id. This mirrors
+// Clair's ext/vulnmdsrc pattern of pluggable vulnerability metadata sources,
+// so new feeds can be added without touching the release lookup path.
+type cveSource interface {
+	Lookup(id string) (cve, error)
+}
+
+// circlSource looks up CVEs against the CIRCL public CVE search API. uri is
+// a fmt-style template taking the CVE id, defaulted to circlCveUri by
+// newCirclSource and overridden in tests to point at a fake server.
+type circlSource struct {
+	client *retryablehttp.Client
+	uri    string
+}
+
+func newCirclSource(client *retryablehttp.Client) *circlSource {
+	return &circlSource{client: client, uri: circlCveUri}
+}
+
+func (s *circlSource) Lookup(id string) (cve, error) {
+	result, err := observedGetJSON(s.client, fmt.Sprintf(s.uri, id), "circl")
+	if err != nil {
+		return cve{}, err
+	}
+
+	cvssString, ok := result["cvss"].(string)
+	if !ok {
+		return cve{}, errors.New("No CVSS found!")
+	}
+	cvss, err := strconv.ParseFloat(cvssString, 64)
+	if err != nil {
+		return cve{}, fmt.Errorf("Cannot parse CVSS %s because %v", cvssString, err.Error())
+	}
+
+	return cve{ID: id, CVSS: cvss, CVSSv2: cvss, Severity: severityFromScore(cvss)}, nil
+}
+
+// nvdSource looks up CVEs against the NIST National Vulnerability Database,
+// preferring the CVSSv3 base score and falling back to CVSSv2 when a v3
+// score hasn't been published yet.
+// nvdSource's uri is a fmt-style template taking the CVE id, defaulted to
+// nvdCveUri by newNvdSource and overridden in tests to point at a fake
+// server.
+type nvdSource struct {
+	client *retryablehttp.Client
+	uri    string
+}
+
+func newNvdSource(client *retryablehttp.Client) *nvdSource {
+	return &nvdSource{client: client, uri: nvdCveUri}
+}
+
+func (s *nvdSource) Lookup(id string) (cve, error) {
+	result, err := observedGetJSON(s.client, fmt.Sprintf(s.uri, id), "nvd")
+	if err != nil {
+		return cve{}, err
+	}
+
+	vulnerabilities, ok := result["vulnerabilities"].([]interface{})
+	if !ok || len(vulnerabilities) == 0 {
+		return cve{}, errors.New("No NVD entry found!")
+	}
+
+	vulnerability, ok := vulnerabilities[0].(map[string]interface{})
+	if !ok {
+		return cve{}, errors.New("Unexpected NVD response shape")
+	}
+
+	cveData, ok := vulnerability["cve"].(map[string]interface{})
+	if !ok {
+		return cve{}, errors.New("Unexpected NVD response shape")
+	}
+
+	metrics, ok := cveData["metrics"].(map[string]interface{})
+	if !ok {
+		return cve{}, errors.New("No metrics in NVD response")
+	}
+
+	found := cve{ID: id}
+
+	if cvssData, vector, severity, ok := nvdCvssMetric(metrics, "cvssMetricV31", "cvssMetricV30"); ok {
+		if score, ok := cvssData["baseScore"].(float64); ok {
+			found.CVSSv3 = score
+			found.CVSS = score
+		}
+		found.Vector = vector
+		found.Severity = severity
+	}
+
+	if cvssData, _, severity, ok := nvdCvssMetric(metrics, "cvssMetricV2"); ok {
+		if score, ok := cvssData["baseScore"].(float64); ok {
+			found.CVSSv2 = score
+			if found.CVSS == 0 {
+				found.CVSS = score
+			}
+		}
+		if found.Severity == "" {
+			found.Severity = severity
+		}
+	}
+
+	if found.CVSSv3 == 0 && found.CVSSv2 == 0 {
+		return cve{}, errors.New("No CVSS score found in NVD response")
+	}
+
+	if found.Severity == "" {
+		found.Severity = severityFromScore(found.CVSS)
+	}
+
+	return found, nil
+}
+
+// nvdCvssMetric returns the cvssData object, vector string and severity of
+// the first populated metric among keys (e.g. "cvssMetricV31" before
+// "cvssMetricV30"), as reported in the NVD CVE API 2.0's metrics object:
+// metrics.<key>[0].{cvssData,baseSeverity}.
+func nvdCvssMetric(metrics map[string]interface{}, keys ...string) (cvssData map[string]interface{}, vector string, severity string, ok bool) {
+	for _, key := range keys {
+		entries, found := metrics[key].([]interface{})
+		if !found || len(entries) == 0 {
+			continue
+		}
+
+		entry, found := entries[0].(map[string]interface{})
+		if !found {
+			continue
+		}
+
+		cvssData, found = entry["cvssData"].(map[string]interface{})
+		if !found {
+			continue
+		}
+
+		if v, ok := cvssData["vectorString"].(string); ok {
+			vector = v
+		}
+		if s, ok := entry["baseSeverity"].(string); ok {
+			severity = s
+		} else if s, ok := cvssData["baseSeverity"].(string); ok {
+			severity = s
+		}
+
+		return cvssData, vector, severity, true
+	}
+
+	return nil, "", "", false
+}
+
+// newCVESources builds the primary and (optional) fallback cveSource for the
+// given --cve-source name, returning an error if name isn't recognised.
+func newCVESources(client *retryablehttp.Client, name string, withFallback bool) (cveSource, cveSource, error) {
+	circl := newCirclSource(client)
+	nvd := newNvdSource(client)
+
+	var primary, fallback cveSource
+	switch name {
+	case "circl":
+		primary, fallback = circl, nvd
+	case "nvd":
+		primary, fallback = nvd, circl
+	default:
+		return nil, nil, fmt.Errorf("Unknown cve-source %q, expected circl or nvd", name)
+	}
+
+	if !withFallback {
+		fallback = nil
+	}
+
+	return primary, fallback, nil
+}
+
+// severityFromScore maps a CVSS base score onto the qualitative severity
+// bands used throughout the CVSSv3 spec, for sources that don't report
+// their own severity rating (e.g. CIRCL).
+func severityFromScore(score float64) string {
+	switch {
+	case score >= 9.0:
+		return "CRITICAL"
+	case score >= 7.0:
+		return "HIGH"
+	case score >= 4.0:
+		return "MEDIUM"
+	case score > 0:
+		return "LOW"
+	default:
+		return ""
+	}
+}
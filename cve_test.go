@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSeverityFromScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		score float64
+		want  string
+	}{
+		{"critical floor", 9.0, "CRITICAL"},
+		{"critical", 10.0, "CRITICAL"},
+		{"high floor", 7.0, "HIGH"},
+		{"high", 8.9, "HIGH"},
+		{"medium floor", 4.0, "MEDIUM"},
+		{"medium", 6.9, "MEDIUM"},
+		{"low", 0.1, "LOW"},
+		{"zero", 0, ""},
+		{"negative", -1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := severityFromScore(tt.score); got != tt.want {
+				t.Errorf("severityFromScore(%v) = %q, want %q", tt.score, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNvdCvssMetric(t *testing.T) {
+	tests := []struct {
+		name         string
+		metrics      map[string]interface{}
+		keys         []string
+		wantScore    float64
+		wantVector   string
+		wantSeverity string
+		wantOK       bool
+	}{
+		{
+			name: "v31 present",
+			metrics: map[string]interface{}{
+				"cvssMetricV31": []interface{}{
+					map[string]interface{}{
+						"baseSeverity": "HIGH",
+						"cvssData": map[string]interface{}{
+							"baseScore":    8.1,
+							"vectorString": "CVSS:3.1/AV:N",
+						},
+					},
+				},
+			},
+			keys:         []string{"cvssMetricV31", "cvssMetricV30"},
+			wantScore:    8.1,
+			wantVector:   "CVSS:3.1/AV:N",
+			wantSeverity: "HIGH",
+			wantOK:       true,
+		},
+		{
+			name: "falls back to v30 when v31 absent",
+			metrics: map[string]interface{}{
+				"cvssMetricV30": []interface{}{
+					map[string]interface{}{
+						"baseSeverity": "MEDIUM",
+						"cvssData":     map[string]interface{}{"baseScore": 5.5},
+					},
+				},
+			},
+			keys:         []string{"cvssMetricV31", "cvssMetricV30"},
+			wantScore:    5.5,
+			wantSeverity: "MEDIUM",
+			wantOK:       true,
+		},
+		{
+			name:    "no matching key",
+			metrics: map[string]interface{}{"cvssMetricV2": []interface{}{}},
+			keys:    []string{"cvssMetricV31", "cvssMetricV30"},
+			wantOK:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, vector, severity, ok := nvdCvssMetric(tt.metrics, tt.keys...)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if score, _ := data["baseScore"].(float64); score != tt.wantScore {
+				t.Errorf("baseScore = %v, want %v", score, tt.wantScore)
+			}
+			if vector != tt.wantVector {
+				t.Errorf("vector = %q, want %q", vector, tt.wantVector)
+			}
+			if severity != tt.wantSeverity {
+				t.Errorf("severity = %q, want %q", severity, tt.wantSeverity)
+			}
+		})
+	}
+}
+
+func TestNvdSourceLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantErr     bool
+		wantCVSS    float64
+		wantCVSSv3  float64
+		wantCVSSv2  float64
+		wantSeverit string
+	}{
+		{
+			name: "prefers v31 over v2",
+			body: `{"vulnerabilities":[{"cve":{"metrics":{
+				"cvssMetricV31":[{"baseSeverity":"CRITICAL","cvssData":{"baseScore":9.8,"vectorString":"CVSS:3.1/AV:N"}}],
+				"cvssMetricV2":[{"severity":"HIGH","cvssData":{"baseScore":7.5}}]
+			}}}]}`,
+			wantCVSS:    9.8,
+			wantCVSSv3:  9.8,
+			wantCVSSv2:  7.5,
+			wantSeverit: "CRITICAL",
+		},
+		{
+			name: "falls back to v2 when no v3 metric",
+			body: `{"vulnerabilities":[{"cve":{"metrics":{
+				"cvssMetricV2":[{"severity":"MEDIUM","cvssData":{"baseScore":5.0}}]
+			}}}]}`,
+			wantCVSS:    5.0,
+			wantCVSSv2:  5.0,
+			wantSeverit: "MEDIUM",
+		},
+		{
+			name:    "no vulnerabilities",
+			body:    `{"vulnerabilities":[]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no cvss data at all",
+			body:    `{"vulnerabilities":[{"cve":{"metrics":{}}}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			source := newNvdSource(newRetryableClient(&http.Client{}))
+			source.uri = server.URL + "/%s"
+
+			found, err := source.Lookup("CVE-2024-12345")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found.CVSS != tt.wantCVSS {
+				t.Errorf("CVSS = %v, want %v", found.CVSS, tt.wantCVSS)
+			}
+			if found.CVSSv3 != tt.wantCVSSv3 {
+				t.Errorf("CVSSv3 = %v, want %v", found.CVSSv3, tt.wantCVSSv3)
+			}
+			if found.CVSSv2 != tt.wantCVSSv2 {
+				t.Errorf("CVSSv2 = %v, want %v", found.CVSSv2, tt.wantCVSSv2)
+			}
+			if found.Severity != tt.wantSeverit {
+				t.Errorf("Severity = %q, want %q", found.Severity, tt.wantSeverit)
+			}
+		})
+	}
+}
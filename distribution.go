@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// distributionProvider abstracts the CoreOS-family distribution being
+// tracked: where to fetch a channel's current version marker, where to fetch
+// the releases.json-shaped feed that enriches a version with its security
+// fixes, and how to parse the version marker's response body.
+type distributionProvider interface {
+	Name() string
+	VersionURI(channel string) string
+	ReleasesURI(channel string) string
+	ParseVersion(body string) (string, error)
+}
+
+// coreOSDistribution tracks CoreOS Container Linux, the original upstream
+// this tool was written against.
+type coreOSDistribution struct{}
+
+func (coreOSDistribution) Name() string { return "coreos" }
+
+func (coreOSDistribution) VersionURI(channel string) string {
+	return fmt.Sprintf("https://%s.release.core-os.net/amd64-usr/current/version.txt", channel)
+}
+
+func (coreOSDistribution) ReleasesURI(channel string) string {
+	return "https://coreos.com/releases/releases.json"
+}
+
+func (coreOSDistribution) ParseVersion(body string) (string, error) {
+	return parseVersionMarker(body, "COREOS_VERSION=")
+}
+
+// flatcarDistribution tracks Flatcar Container Linux, the community
+// continuation of CoreOS Container Linux.
+type flatcarDistribution struct{}
+
+func (flatcarDistribution) Name() string { return "flatcar" }
+
+func (flatcarDistribution) VersionURI(channel string) string {
+	return fmt.Sprintf("https://%s.release.flatcar-linux.net/amd64-usr/current/version.txt", channel)
+}
+
+func (flatcarDistribution) ReleasesURI(channel string) string {
+	return fmt.Sprintf("https://www.flatcar-linux.org/releases-json/releases-%s.json", channel)
+}
+
+func (flatcarDistribution) ParseVersion(body string) (string, error) {
+	// Flatcar kept the COREOS_VERSION= marker for a while for compatibility
+	// with tooling written against CoreOS Container Linux.
+	return parseVersionMarker(body, "FLATCAR_VERSION=", "COREOS_VERSION=")
+}
+
+func parseVersionMarker(body string, prefixes ...string) (string, error) {
+	lines := strings.Split(body, "\n")
+	for _, prefix := range prefixes {
+		for _, line := range lines {
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimPrefix(line, prefix), nil
+			}
+		}
+	}
+
+	return "", errors.New("No version found in upstream response")
+}
+
+// newDistributionProvider resolves the --distribution flag to a concrete
+// distributionProvider, returning an error if the name isn't recognised.
+func newDistributionProvider(name string) (distributionProvider, error) {
+	switch name {
+	case "coreos":
+		return coreOSDistribution{}, nil
+	case "flatcar":
+		return flatcarDistribution{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown distribution %q, expected coreos or flatcar", name)
+	}
+}
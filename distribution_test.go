@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestFlatcarDistributionParseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{"FLATCAR_VERSION present", "FLATCAR_VERSION=3200.0.0\nFLATCAR_BUILD_ID=abc\n", "3200.0.0", false},
+		{"falls back to COREOS_VERSION", "NAME=Flatcar\nCOREOS_VERSION=2605.0.0\n", "2605.0.0", false},
+		{"prefers FLATCAR_VERSION when both present on the same line scan", "FLATCAR_VERSION=3200.0.0\nCOREOS_VERSION=2605.0.0\n", "3200.0.0", false},
+		{"neither marker present", "NAME=Flatcar\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := flatcarDistribution{}.ParseVersion(tt.body)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoreOSDistributionParseVersion(t *testing.T) {
+	got, err := coreOSDistribution{}.ParseVersion("COREOS_VERSION=2345.3.0\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "2345.3.0" {
+		t.Errorf("ParseVersion() = %q, want 2345.3.0", got)
+	}
+
+	if _, err := (coreOSDistribution{}).ParseVersion("NAME=CoreOS\n"); err == nil {
+		t.Fatal("expected an error when COREOS_VERSION is absent")
+	}
+}
+
+func TestNewDistributionProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "coreos", want: "coreos"},
+		{name: "flatcar", want: "flatcar"},
+		{name: "debian", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newDistributionProvider(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error for distribution %q, got none", tt.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Name() != tt.want {
+				t.Errorf("Name() = %q, want %q", got.Name(), tt.want)
+			}
+		})
+	}
+}
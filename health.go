@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1a"
+	"github.com/Financial-Times/service-status-go/gtg"
+)
+
+const (
+	appSystemCode = "coreos-version-checker"
+	appName       = "CoreOS Version Checker"
+)
+
+// HealthConfig holds the thresholds at which the health check and GTG should
+// start reporting an unhealthy service, rather than just surfacing numbers
+// for something else to interpret.
+type HealthConfig struct {
+	MaxAllowedCVSS          float64
+	MaxAllowedDriftReleases int
+}
+
+// HealthService reports on the health of the underlying releaseRepository:
+// whether it can reach its upstreams, and whether the installed version is
+// within the configured CVSS and drift thresholds.
+type HealthService struct {
+	repo   *releaseRepository
+	config HealthConfig
+}
+
+func NewHealthService(repo *releaseRepository, config HealthConfig) *HealthService {
+	return &HealthService{repo: repo, config: config}
+}
+
+func (h *HealthService) HealthCheckHandler() func(w http.ResponseWriter, r *http.Request) {
+	checks := append([]fthealth.Check{h.pollCheck()}, h.channelChecks()...)
+	return fthealth.Handler(appSystemCode, appName, checks...)
+}
+
+func (h *HealthService) GTG() gtg.Status {
+	checks := append([]fthealth.Check{h.pollCheck()}, h.channelChecks()...)
+	for _, check := range checks {
+		if _, err := check.Checker(); err != nil {
+			return gtg.Status{GoodToGo: false, Message: err.Error()}
+		}
+	}
+	return gtg.Status{GoodToGo: true}
+}
+
+func (h *HealthService) pollCheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "CoreOS upgrade and CVE information may be stale or unavailable.",
+		Name:             "CoreOS release poll",
+		PanicGuide:       "Check connectivity to the CoreOS release feed and the configured CVE sources.",
+		Severity:         2,
+		TechnicalSummary: "Checks that the most recent poll of the CoreOS release feeds succeeded. Failed CVE lookups are not reported by this check.",
+		Checker:          h.checkPoll,
+	}
+}
+
+func (h *HealthService) checkPoll() (string, error) {
+	h.repo.RLock()
+	defer h.repo.RUnlock()
+
+	if h.repo.err != nil {
+		return "", h.repo.err
+	}
+
+	return fmt.Sprintf("Installed version %s on %s channel %s", h.repo.installedVersion.Version, h.repo.distribution.Name(), h.repo.channel), nil
+}
+
+// channelChecks returns one threshold check per channel the repository
+// tracks, so a single channel going stale or over-threshold doesn't hide
+// behind an aggregate result.
+func (h *HealthService) channelChecks() []fthealth.Check {
+	h.repo.RLock()
+	channels := append([]string(nil), h.repo.channels...)
+	distribution := h.repo.distribution.Name()
+	h.repo.RUnlock()
+
+	checks := make([]fthealth.Check, 0, len(channels))
+	for _, channel := range channels {
+		channel := channel
+		checks = append(checks, fthealth.Check{
+			BusinessImpact:   "The installed CoreOS version carries a CVE risk, or is too far behind the latest release on this channel, that exceeds agreed thresholds.",
+			Name:             fmt.Sprintf("%s %s channel drift and CVSS thresholds", distribution, channel),
+			PanicGuide:       "Schedule an upgrade of the affected host(s) to a release within the configured CVSS and drift thresholds.",
+			Severity:         1,
+			TechnicalSummary: fmt.Sprintf("Fails when the installed version's MaxCVSS exceeds %.1f or it is more than %d releases behind the %s channel. A threshold of 0 disables that part of the check.", h.config.MaxAllowedCVSS, h.config.MaxAllowedDriftReleases, channel),
+			Checker:          func() (string, error) { return h.checkThresholds(channel) },
+		})
+	}
+	return checks
+}
+
+func (h *HealthService) checkThresholds(channel string) (string, error) {
+	h.repo.RLock()
+	defer h.repo.RUnlock()
+
+	if err := h.repo.channelErr[channel]; err != nil {
+		return "", fmt.Errorf("Last poll of the %s channel failed, so its drift and CVSS data may be stale: %v", channel, err)
+	}
+
+	if h.repo.installedVersion.MaxCVSS != nil && h.config.MaxAllowedCVSS > 0 && *h.repo.installedVersion.MaxCVSS > h.config.MaxAllowedCVSS {
+		return "", fmt.Errorf("Installed version %s has MaxCVSS %.1f, which exceeds the allowed threshold of %.1f", h.repo.installedVersion.Version, *h.repo.installedVersion.MaxCVSS, h.config.MaxAllowedCVSS)
+	}
+
+	drift := h.repo.driftByChannel[channel]
+	if h.config.MaxAllowedDriftReleases > 0 && drift > h.config.MaxAllowedDriftReleases {
+		return "", fmt.Errorf("Installed version %s is %d releases behind %s channel %s, which exceeds the allowed drift of %d", h.repo.installedVersion.Version, drift, channel, h.repo.latestByChannel[channel].Version, h.config.MaxAllowedDriftReleases)
+	}
+
+	return fmt.Sprintf("Installed version is within the configured CVSS and drift thresholds for channel %s (drift: %d)", channel, drift), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// GetJSON performs a GET request against uri and decodes the response body
+// into a generic map, the shape most of the upstream CoreOS and CVE APIs
+// return.
+func GetJSON(client *retryablehttp.Client, uri string) (map[string]interface{}, error) {
+	req, err := retryablehttp.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Got %v requesting %v", resp.StatusCode, uri)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -9,11 +9,25 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	cli "github.com/jawher/mow.cli"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
 	coreOSUpdateConfPath  *string
 	coreOSReleaseConfPath *string
+	cveSourceName         *string
+	cveSourceFallback     *bool
+	maxCVSSFlag           *float64
+	maxDriftFlag          *int
+	metricsPath           *string
+	cveCacheTTL           *string
+	cachePath             *string
+	cacheDisabled         *bool
+	notifyWebhooks        *[]string
+	notifySlacks          *[]string
+	notifyMinCVSS         *float64
+	distributionName      *string
+	extraChannels         *[]string
 )
 
 func main() {
@@ -33,21 +47,154 @@ func main() {
 		EnvVar: "RELEASE_CONF",
 	})
 
+	cveSourceName = app.String(cli.StringOpt{
+		Name:   "cve-source",
+		Value:  "circl",
+		Desc:   "The primary CVE data source to use: circl or nvd.",
+		EnvVar: "CVE_SOURCE",
+	})
+
+	cveSourceFallback = app.Bool(cli.BoolOpt{
+		Name:   "cve-source-fallback",
+		Value:  false,
+		Desc:   "If set, a failed lookup against the primary CVE source is retried against the other source.",
+		EnvVar: "CVE_SOURCE_FALLBACK",
+	})
+
+	maxCVSSFlag = app.Float64(cli.Float64Opt{
+		Name:   "max-cvss",
+		Value:  0,
+		Desc:   "The maximum CVSS score the installed version's MaxCVSS may reach before /__health and /__gtg report unhealthy. 0 disables the check.",
+		EnvVar: "MAX_CVSS",
+	})
+
+	maxDriftFlag = app.Int(cli.IntOpt{
+		Name:   "max-drift",
+		Value:  0,
+		Desc:   "The maximum number of releases the installed version may fall behind before /__health and /__gtg report unhealthy. 0 disables the check.",
+		EnvVar: "MAX_DRIFT",
+	})
+
+	metricsPath = app.String(cli.StringOpt{
+		Name:   "metrics-path",
+		Value:  "/__metrics",
+		Desc:   "The path to expose Prometheus metrics on.",
+		EnvVar: "METRICS_PATH",
+	})
+
+	cveCacheTTL = app.String(cli.StringOpt{
+		Name:   "cve-cache-ttl",
+		Value:  "24h",
+		Desc:   "How long a cached CVE or release lookup is considered fresh, e.g. 24h.",
+		EnvVar: "CVE_CACHE_TTL",
+	})
+
+	cachePath = app.String(cli.StringOpt{
+		Name:   "cache-path",
+		Value:  "/var/cache/coreos-version-checker",
+		Desc:   "The directory to store the on-disk CVE/release cache in.",
+		EnvVar: "CACHE_PATH",
+	})
+
+	cacheDisabled = app.Bool(cli.BoolOpt{
+		Name:   "cache-disabled",
+		Value:  false,
+		Desc:   "If set, CVE and release lookups always hit the network and are never cached on disk.",
+		EnvVar: "CACHE_DISABLED",
+	})
+
+	notifyWebhooks = app.Strings(cli.StringsOpt{
+		Name:   "notify-webhook",
+		Value:  []string{},
+		Desc:   "A URL to POST a JSON notification to whenever a new high-severity CVE or release is observed. Repeatable.",
+		EnvVar: "NOTIFY_WEBHOOK",
+	})
+
+	notifySlacks = app.Strings(cli.StringsOpt{
+		Name:   "notify-slack",
+		Value:  []string{},
+		Desc:   "A Slack incoming webhook URL to notify whenever a new high-severity CVE or release is observed. Repeatable.",
+		EnvVar: "NOTIFY_SLACK",
+	})
+
+	notifyMinCVSS = app.Float64(cli.Float64Opt{
+		Name:   "notify-min-cvss",
+		Value:  7.0,
+		Desc:   "The minimum CVSS score a CVE or release must have before a notification is dispatched for it.",
+		EnvVar: "NOTIFY_MIN_CVSS",
+	})
+
+	distributionName = app.String(cli.StringOpt{
+		Name:   "distribution",
+		Value:  "coreos",
+		Desc:   "The CoreOS-family distribution to track: coreos or flatcar.",
+		EnvVar: "DISTRIBUTION",
+	})
+
+	extraChannels = app.Strings(cli.StringsOpt{
+		Name:   "extra-channel",
+		Value:  []string{},
+		Desc:   "An additional release channel to monitor alongside stable, beta and alpha. Repeatable.",
+		EnvVar: "EXTRA_CHANNELS",
+	})
+
 	app.Action = func() {
 		log.SetFormatter(&log.JSONFormatter{})
-		log.WithField("update-conf", *coreOSUpdateConfPath).WithField("release-conf", *coreOSReleaseConfPath).Info("Started with provided config.")
+		log.WithField("update-conf", *coreOSUpdateConfPath).WithField("release-conf", *coreOSReleaseConfPath).WithField("cve-source", *cveSourceName).Info("Started with provided config.")
 
 		client := &http.Client{Timeout: 1500 * time.Millisecond}
-		repo := newReleaseRepository(client, *coreOSReleaseConfPath, *coreOSUpdateConfPath)
-		healthService := NewHealthService(repo)
-		go startPoll(time.Minute*30, repo)
+		retryableClient := newRetryableClient(client)
+
+		source, fallback, err := newCVESources(retryableClient, *cveSourceName, *cveSourceFallback)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid --cve-source")
+		}
+
+		distribution, err := newDistributionProvider(*distributionName)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid --distribution")
+		}
+		channels := dedupeChannels(append([]string{"stable", "beta", "alpha"}, *extraChannels...))
+
+		var cache cveCache
+		if !*cacheDisabled {
+			ttl, err := time.ParseDuration(*cveCacheTTL)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid --cve-cache-ttl")
+			}
+
+			if err := os.MkdirAll(*cachePath, 0755); err != nil {
+				log.WithError(err).Fatal("Failed to create --cache-path")
+			}
+
+			boltCache, err := newBoltCache(*cachePath, ttl)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to open the on-disk cache")
+			}
+			defer boltCache.Close()
+			cache = boltCache
+		}
+
+		repo := newReleaseRepository(client, *coreOSReleaseConfPath, *coreOSUpdateConfPath, source, fallback, cache, distribution, channels)
+		healthService := NewHealthService(repo, HealthConfig{MaxAllowedCVSS: *maxCVSSFlag, MaxAllowedDriftReleases: *maxDriftFlag})
+
+		var notifiers []Notifier
+		for _, url := range *notifyWebhooks {
+			notifiers = append(notifiers, newWebhookNotifier(retryableClient, url))
+		}
+		for _, url := range *notifySlacks {
+			notifiers = append(notifiers, newSlackNotifier(retryableClient, url))
+		}
+		dispatcher := newNotifyDispatcher(notifiers, *notifyMinCVSS)
+
+		go startPoll(time.Minute*30, repo, dispatcher)
 
 		mux := mux.NewRouter()
 		mux.HandleFunc("/__health", healthService.HealthCheckHandler()).Methods("GET")
 		mux.HandleFunc(status.GTGPath, status.NewGoodToGoHandler(healthService.GTG))
+		mux.Handle(*metricsPath, promhttp.Handler())
 		log.Printf("Starting http server on 8080\n")
-		err := http.ListenAndServe(":8080", mux)
-		if err != nil {
+		if err := http.ListenAndServe(":8080", mux); err != nil {
 			panic(err)
 		}
 	}
@@ -55,16 +202,59 @@ func main() {
 	app.Run(os.Args)
 }
 
-func startPoll(interval time.Duration, repo *releaseRepository) {
+func startPoll(interval time.Duration, repo *releaseRepository, dispatcher *notifyDispatcher) {
 	err := pollCoreOSReleases(repo)
 	repo.UpdateError(err)
+	previous := snapshotLatest(repo)
 
 	poll := time.NewTicker(interval)
 	for {
 		<-poll.C
 		err := pollCoreOSReleases(repo)
 		repo.UpdateError(err)
+		if err != nil {
+			continue
+		}
+
+		latest := snapshotLatest(repo)
+		for channel, release := range latest.releases {
+			notifyDrift(dispatcher, previous.releases[channel], release, channel)
+		}
+		previous = latest
+	}
+}
+
+// latestSnapshot is a consistent read of a releaseRepository's latest known
+// release per channel, taken under its read lock.
+type latestSnapshot struct {
+	releases map[string]coreOSRelease
+}
+
+func snapshotLatest(repo *releaseRepository) latestSnapshot {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	releases := make(map[string]coreOSRelease, len(repo.latestByChannel))
+	for channel, release := range repo.latestByChannel {
+		releases[channel] = release
+	}
+	return latestSnapshot{releases: releases}
+}
+
+// dedupeChannels preserves the order channels were first seen in, while
+// dropping repeats, so a channel passed via --extra-channel that's already
+// one of the defaults doesn't get polled twice.
+func dedupeChannels(channels []string) []string {
+	seen := make(map[string]struct{}, len(channels))
+	result := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		if _, ok := seen[channel]; ok {
+			continue
+		}
+		seen[channel] = struct{}{}
+		result = append(result, channel)
 	}
+	return result
 }
 
 func pollCoreOSReleases(repo *releaseRepository) error {
@@ -80,11 +270,12 @@ func pollCoreOSReleases(repo *releaseRepository) error {
 		return err
 	}
 
-	err = repo.GetLatestVersion()
+	err = repo.GetLatestVersions()
 	if err != nil {
-		log.WithError(err).Error("Failed to retrieve the latest remote coreOS Release.")
+		log.WithError(err).Error("Failed to retrieve the latest remote releases.")
 		return err
 	}
 
+	updateReleaseMetrics(repo)
 	return nil
 }
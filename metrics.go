@@ -0,0 +1,98 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	installedVersionInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coreos_installed_version_info",
+		Help: "Info metric (constant 1) labelled with the currently installed CoreOS version.",
+	}, []string{"version"})
+
+	latestVersionInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coreos_latest_version_info",
+		Help: "Info metric (constant 1) labelled with the latest known CoreOS version for a channel.",
+	}, []string{"channel", "version"})
+
+	versionDriftReleases = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coreos_version_drift_releases",
+		Help: "The number of releases the installed version is behind the latest known release, labelled by channel.",
+	}, []string{"channel"})
+
+	maxCVSS = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coreos_max_cvss",
+		Help: "The highest CVSS score amongst a release's security fixes.",
+	}, []string{"channel", "scope"})
+
+	pollTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "coreos_version_checker_poll_total",
+		Help: "Count of upstream polls, labelled by source and outcome.",
+	}, []string{"source", "outcome"})
+
+	upstreamLatencySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "coreos_version_checker_upstream_latency_seconds",
+		Help:    "Latency of upstream HTTP calls, labelled by source.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"source"})
+
+	cacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "coreos_version_checker_cache_hits_total",
+		Help: "Count of on-disk cache lookups that were served from the cache, labelled by kind (cve, release, feed).",
+	}, []string{"kind"})
+
+	cacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "coreos_version_checker_cache_misses_total",
+		Help: "Count of on-disk cache lookups that missed (absent or expired), labelled by kind (cve, release, feed).",
+	}, []string{"kind"})
+)
+
+func init() {
+	prometheus.MustRegister(installedVersionInfo, latestVersionInfo, versionDriftReleases, maxCVSS, pollTotal, upstreamLatencySeconds, cacheHits, cacheMisses)
+}
+
+// observedGetJSON wraps GetJSON, recording the call's latency and outcome
+// against source so the poll/failure counters and latency histogram stay
+// accurate without every call site having to remember to do it.
+func observedGetJSON(client *retryablehttp.Client, uri string, source string) (map[string]interface{}, error) {
+	start := time.Now()
+	result, err := GetJSON(client, uri)
+	upstreamLatencySeconds.WithLabelValues(source).Observe(time.Since(start).Seconds())
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	pollTotal.WithLabelValues(source, outcome).Inc()
+
+	return result, err
+}
+
+// updateReleaseMetrics refreshes the release-derived gauges after a poll.
+func updateReleaseMetrics(repo *releaseRepository) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	if repo.installedVersion.Version != "" {
+		installedVersionInfo.Reset()
+		installedVersionInfo.WithLabelValues(repo.installedVersion.Version).Set(1)
+		if repo.installedVersion.MaxCVSS != nil {
+			maxCVSS.WithLabelValues(repo.channel, "installed").Set(*repo.installedVersion.MaxCVSS)
+		}
+	}
+
+	if len(repo.latestByChannel) > 0 {
+		latestVersionInfo.Reset()
+		versionDriftReleases.Reset()
+		for channel, latest := range repo.latestByChannel {
+			latestVersionInfo.WithLabelValues(channel, latest.Version).Set(1)
+			if latest.MaxCVSS != nil {
+				maxCVSS.WithLabelValues(channel, "latest").Set(*latest.MaxCVSS)
+			}
+			versionDriftReleases.WithLabelValues(channel).Set(float64(repo.driftByChannel[channel]))
+		}
+	}
+}
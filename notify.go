@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	logrus "github.com/Sirupsen/logrus"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// notifyWorkers bounds how many notifications are in flight at once, so a
+// slow or unreachable notify endpoint can't stall the poll loop.
+const notifyWorkers = 4
+
+// notificationEvent describes something worth telling the outside world
+// about: either a newly observed CVE in a release's security fixes, or a
+// newly available release whose MaxCVSS is at or above the configured
+// threshold.
+type notificationEvent struct {
+	Kind            string  `json:"kind"` // "cve" or "release"
+	CVEID           string  `json:"cveId,omitempty"`
+	CVSS            float64 `json:"cvss"`
+	Version         string  `json:"version"`
+	Channel         string  `json:"channel"`
+	ReleaseNotesURL string  `json:"releaseNotesUrl,omitempty"`
+}
+
+// Notifier is told about notificationEvents as they're observed, modeled on
+// Clair's ext/notification package.
+type Notifier interface {
+	Notify(ctx context.Context, event notificationEvent) error
+}
+
+// webhookNotifier POSTs the event as JSON to a generic HTTP endpoint.
+type webhookNotifier struct {
+	client *retryablehttp.Client
+	url    string
+}
+
+func newWebhookNotifier(client *retryablehttp.Client, url string) *webhookNotifier {
+	return &webhookNotifier{client: client, url: url}
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, event notificationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.client, n.url, body)
+}
+
+// slackNotifier formats the event as a Slack incoming webhook message.
+type slackNotifier struct {
+	client *retryablehttp.Client
+	url    string
+}
+
+func newSlackNotifier(client *retryablehttp.Client, url string) *slackNotifier {
+	return &slackNotifier{client: client, url: url}
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, event notificationEvent) error {
+	body, err := json.Marshal(map[string]string{"text": formatSlackMessage(event)})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.client, n.url, body)
+}
+
+func formatSlackMessage(event notificationEvent) string {
+	if event.Kind == "cve" {
+		return fmt.Sprintf(":rotating_light: New %s-severity CVE %s (CVSS %.1f) affects CoreOS %s on %s", severityFromScore(event.CVSS), event.CVEID, event.CVSS, event.Version, event.Channel)
+	}
+	return fmt.Sprintf(":rocket: New CoreOS release %s available on %s (MaxCVSS %.1f) - %s", event.Version, event.Channel, event.CVSS, event.ReleaseNotesURL)
+}
+
+func postJSON(ctx context.Context, client *retryablehttp.Client, url string, body []byte) error {
+	req, err := retryablehttp.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Got %v posting notification to %v", resp.StatusCode, url)
+	}
+	return nil
+}
+
+// notifyDispatcher fans events out to a bounded pool of workers, each of
+// which pushes the event to every registered Notifier.
+type notifyDispatcher struct {
+	notifiers []Notifier
+	minCVSS   float64
+	events    chan notificationEvent
+}
+
+func newNotifyDispatcher(notifiers []Notifier, minCVSS float64) *notifyDispatcher {
+	d := &notifyDispatcher{notifiers: notifiers, minCVSS: minCVSS, events: make(chan notificationEvent, 100)}
+	for i := 0; i < notifyWorkers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+func (d *notifyDispatcher) worker() {
+	for event := range d.events {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		for _, notifier := range d.notifiers {
+			if err := notifier.Notify(ctx, event); err != nil {
+				logrus.WithError(err).WithField("event", event).Warn("Failed to dispatch notification")
+			}
+		}
+		cancel()
+	}
+}
+
+// Dispatch enqueues event for delivery if it meets the configured minimum
+// CVSS, without blocking the caller. If the queue is full the event is
+// dropped rather than stalling the poll loop.
+func (d *notifyDispatcher) Dispatch(event notificationEvent) {
+	if event.CVSS < d.minCVSS {
+		return
+	}
+
+	select {
+	case d.events <- event:
+	default:
+		logrus.WithField("event", event).Warn("Notification queue full, dropping event")
+	}
+}
+
+// notifyDrift dispatches an event for each CVE in latest.SecurityFixes that
+// wasn't present in previous.SecurityFixes, and one further event if latest
+// has a MaxCVSS and its version differs from a known previous.Version. No
+// release event is sent when previous has no version (e.g. the first poll).
+func notifyDrift(dispatcher *notifyDispatcher, previous coreOSRelease, latest coreOSRelease, channel string) {
+	seen := make(map[string]struct{}, len(previous.SecurityFixes))
+	for _, fix := range previous.SecurityFixes {
+		seen[fix.ID] = struct{}{}
+	}
+
+	for _, fix := range latest.SecurityFixes {
+		if _, ok := seen[fix.ID]; ok {
+			continue
+		}
+		dispatcher.Dispatch(notificationEvent{
+			Kind:            "cve",
+			CVEID:           fix.ID,
+			CVSS:            fix.CVSS,
+			Version:         latest.Version,
+			Channel:         channel,
+			ReleaseNotesURL: latest.ReleaseNotes,
+		})
+	}
+
+	if previous.Version != "" && latest.Version != previous.Version && latest.MaxCVSS != nil {
+		dispatcher.Dispatch(notificationEvent{
+			Kind:            "release",
+			CVSS:            *latest.MaxCVSS,
+			Version:         latest.Version,
+			Channel:         channel,
+			ReleaseNotesURL: latest.ReleaseNotes,
+		})
+	}
+}
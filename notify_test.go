@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// recordingNotifier captures every event it's given, for assertions in
+// notifyDrift tests.
+type recordingNotifier struct {
+	events chan notificationEvent
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{events: make(chan notificationEvent, 10)}
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event notificationEvent) error {
+	n.events <- event
+	return nil
+}
+
+func (n *recordingNotifier) drain(t *testing.T, want int) []notificationEvent {
+	t.Helper()
+	events := make([]notificationEvent, 0, want)
+	for i := 0; i < want; i++ {
+		select {
+		case event := <-n.events:
+			events = append(events, event)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %d/%d", i+1, want)
+		}
+	}
+	select {
+	case extra := <-n.events:
+		t.Fatalf("got unexpected extra event: %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return events
+}
+
+func TestNotifyDrift(t *testing.T) {
+	maxCVSS := 8.5
+
+	t.Run("dispatches a cve event for a newly observed fix", func(t *testing.T) {
+		notifier := newRecordingNotifier()
+		dispatcher := newNotifyDispatcher([]Notifier{notifier}, 0)
+
+		previous := coreOSRelease{Version: "1.0.0"}
+		latest := coreOSRelease{Version: "1.0.0", SecurityFixes: []cve{{ID: "CVE-2024-1", CVSS: 7.2}}}
+
+		notifyDrift(dispatcher, previous, latest, "stable")
+
+		events := notifier.drain(t, 1)
+		if events[0].Kind != "cve" || events[0].CVEID != "CVE-2024-1" {
+			t.Errorf("got %+v, want a cve event for CVE-2024-1", events[0])
+		}
+	})
+
+	t.Run("does not re-notify a fix already seen in the previous snapshot", func(t *testing.T) {
+		notifier := newRecordingNotifier()
+		dispatcher := newNotifyDispatcher([]Notifier{notifier}, 0)
+
+		fix := cve{ID: "CVE-2024-1", CVSS: 7.2}
+		previous := coreOSRelease{Version: "1.0.0", SecurityFixes: []cve{fix}}
+		latest := coreOSRelease{Version: "1.0.0", SecurityFixes: []cve{fix}}
+
+		notifyDrift(dispatcher, previous, latest, "stable")
+		notifier.drain(t, 0)
+	})
+
+	t.Run("does not dispatch a release event on the first poll", func(t *testing.T) {
+		notifier := newRecordingNotifier()
+		dispatcher := newNotifyDispatcher([]Notifier{notifier}, 0)
+
+		previous := coreOSRelease{}
+		latest := coreOSRelease{Version: "1.0.0", MaxCVSS: &maxCVSS}
+
+		notifyDrift(dispatcher, previous, latest, "stable")
+		notifier.drain(t, 0)
+	})
+
+	t.Run("dispatches a release event when the version changes", func(t *testing.T) {
+		notifier := newRecordingNotifier()
+		dispatcher := newNotifyDispatcher([]Notifier{notifier}, 0)
+
+		previous := coreOSRelease{Version: "1.0.0"}
+		latest := coreOSRelease{Version: "1.0.1", MaxCVSS: &maxCVSS}
+
+		notifyDrift(dispatcher, previous, latest, "stable")
+
+		events := notifier.drain(t, 1)
+		if events[0].Kind != "release" || events[0].Version != "1.0.1" {
+			t.Errorf("got %+v, want a release event for 1.0.1", events[0])
+		}
+	})
+}
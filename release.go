@@ -8,8 +8,6 @@ import (
 	"math"
 	"net/http"
 	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -19,15 +17,14 @@ import (
 
 var cveRegex = regexp.MustCompile(`CVE\-[0-9]{4}\-[0-9]{4,}`)
 
-const (
-	cveUri      string = "http://cve.circl.lu/api/cve/%s"
-	releasesUri string = "https://coreos.com/releases/releases.json"
-)
-
 type cve struct {
-	ID   string  `json:"id"`
-	CVSS float64 `json:"cvss"`
-	err  error
+	ID       string  `json:"id"`
+	CVSS     float64 `json:"cvss"`
+	CVSSv2   float64 `json:"cvssV2,omitempty"`
+	CVSSv3   float64 `json:"cvssV3,omitempty"`
+	Severity string  `json:"severity,omitempty"`
+	Vector   string  `json:"vector,omitempty"`
+	err      error
 }
 
 type coreOSRelease struct {
@@ -35,23 +32,33 @@ type coreOSRelease struct {
 	Version       string     `json:"version"`
 	ReleaseNotes  string     `json:"releaseNotes"`
 	MaxCVSS       *float64   `json:"maxCvss,omitempty"`
+	MaxCVSSv3     *float64   `json:"maxCvssV3,omitempty"`
 	ReleasedOn    *time.Time `json:"releasedOn,omitempty"`
 }
 
 type releaseRepository struct {
 	sync.RWMutex
-	client           *retryablehttp.Client
-	channel          string
-	installedVersion coreOSRelease
-	latestVersion    coreOSRelease
-	err              error
-	releaseConfPath  string
-	updateConfPath   string
+	client            *retryablehttp.Client
+	cveSource         cveSource
+	cveSourceFallback cveSource
+	cache             cveCache
+	distribution      distributionProvider
+	channels          []string
+	channel           string
+	installedVersion  coreOSRelease
+	latestByChannel   map[string]coreOSRelease
+	driftByChannel    map[string]int
+	channelErr        map[string]error
+	err               error
+	releaseConfPath   string
+	updateConfPath    string
 }
 
-func newReleaseRepository(client *http.Client, releaseConfPath string, updateConfPath string) *releaseRepository {
+// newRetryableClient wraps client with the retry/backoff policy shared by the
+// release repository and the CVE sources it delegates to.
+func newRetryableClient(client *http.Client) *retryablehttp.Client {
 	logWriter := logrus.StandardLogger().Writer()
-	retryableClient := &retryablehttp.Client{
+	return &retryablehttp.Client{
 		HTTPClient:   client,
 		Logger:       log.New(logWriter, "", log.LstdFlags),
 		RetryWaitMin: 100 * time.Millisecond,
@@ -60,10 +67,22 @@ func newReleaseRepository(client *http.Client, releaseConfPath string, updateCon
 		CheckRetry:   retryablehttp.DefaultRetryPolicy,
 		Backoff:      retryablehttp.DefaultBackoff,
 	}
+}
+
+func newReleaseRepository(client *http.Client, releaseConfPath string, updateConfPath string, source cveSource, fallback cveSource, cache cveCache, distribution distributionProvider, channels []string) *releaseRepository {
+	retryableClient := newRetryableClient(client)
 	return &releaseRepository{
-		client:          retryableClient,
-		releaseConfPath: releaseConfPath,
-		updateConfPath:  updateConfPath,
+		client:            retryableClient,
+		cveSource:         source,
+		cveSourceFallback: fallback,
+		cache:             cache,
+		distribution:      distribution,
+		channels:          channels,
+		latestByChannel:   make(map[string]coreOSRelease),
+		driftByChannel:    make(map[string]int),
+		channelErr:        make(map[string]error),
+		releaseConfPath:   releaseConfPath,
+		updateConfPath:    updateConfPath,
 	}
 }
 
@@ -97,7 +116,11 @@ func (r *releaseRepository) GetInstalledVersion() error {
 		return err
 	}
 
-	enrichedRelease, err := r.Get(release)
+	r.RLock()
+	channel := r.channel
+	r.RUnlock()
+
+	enrichedRelease, err := r.Get(channel, release)
 	if err != nil {
 		return err
 	}
@@ -109,56 +132,175 @@ func (r *releaseRepository) GetInstalledVersion() error {
 	return nil
 }
 
-func (r *releaseRepository) GetLatestVersion() error {
-	uri := fmt.Sprintf(versionUri, r.channel)
+// GetLatestVersions polls every channel in r.channels in parallel, so a slow
+// or unreachable channel can't hold up the others, and records each
+// channel's latest release and drift from the installed version.
+func (r *releaseRepository) GetLatestVersions() error {
+	r.RLock()
+	channels := append([]string(nil), r.channels...)
+	r.RUnlock()
+
+	type channelResult struct {
+		channel string
+		release *coreOSRelease
+		drift   int
+		err     error
+	}
+
+	results := make(chan channelResult, len(channels))
+	var wg sync.WaitGroup
+	for _, channel := range channels {
+		wg.Add(1)
+		go func(channel string) {
+			defer wg.Done()
+			release, err := r.fetchLatest(channel)
+			if err != nil {
+				results <- channelResult{channel: channel, err: err}
+				return
+			}
+			results <- channelResult{channel: channel, release: release, drift: r.driftFrom(channel, release)}
+		}(channel)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var firstErr error
+	succeeded := 0
+	for result := range results {
+		if result.err != nil {
+			logrus.WithError(result.err).WithField("channel", result.channel).Error("Failed to retrieve the latest release for channel.")
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			r.Lock()
+			r.channelErr[result.channel] = result.err
+			r.Unlock()
+			continue
+		}
+
+		succeeded++
+		r.Lock()
+		r.latestByChannel[result.channel] = *result.release
+		r.driftByChannel[result.channel] = result.drift
+		r.channelErr[result.channel] = nil
+		r.Unlock()
+	}
+
+	if succeeded == 0 {
+		return firstErr
+	}
+	return nil
+}
+
+// fetchLatest resolves channel's current version marker and enriches it via
+// Get, for the repository's configured distributionProvider.
+func (r *releaseRepository) fetchLatest(channel string) (*coreOSRelease, error) {
+	uri := r.distribution.VersionURI(channel)
 	req, err := retryablehttp.NewRequest("GET", uri, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("Got %v requesting %v", resp.StatusCode, uri)
+		return nil, fmt.Errorf("Got %v requesting %v", resp.StatusCode, uri)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	release, err := parseCoreOSVersion(string(body))
+	release, err := r.distribution.ParseVersion(string(body))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	coreOS, err := r.Get(release)
+	return r.Get(channel, release)
+}
+
+// driftFrom counts the releases between the currently installed version and
+// latest, based on their release dates in channel's releases.json-shaped feed.
+func (r *releaseRepository) driftFrom(channel string, latest *coreOSRelease) int {
+	r.RLock()
+	installed := r.installedVersion
+	r.RUnlock()
+
+	if installed.ReleasedOn == nil || latest.ReleasedOn == nil {
+		return 0
+	}
+
+	releases, err := r.releaseFeed(channel)
 	if err != nil {
-		return err
+		return 0
 	}
 
-	r.Lock()
-	defer r.Unlock()
+	drift := 0
+	for _, raw := range releases {
+		data, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		releasedOnText, ok := data["release_date"].(string)
+		if !ok {
+			continue
+		}
+		releasedOn, err := time.Parse("2006-01-02 15:04:05 -0700", releasedOnText)
+		if err != nil {
+			continue
+		}
+		if releasedOn.After(*installed.ReleasedOn) && !releasedOn.After(*latest.ReleasedOn) {
+			drift++
+		}
+	}
+	return drift
+}
 
-	r.latestVersion = *coreOS
-	return nil
+// cacheKey namespaces a cache key by the repository's distribution, so
+// switching --distribution on a host that keeps the default --cache-path
+// can't serve the other distribution's cached channel feeds or releases.
+func (r *releaseRepository) cacheKey(key string) string {
+	return r.distribution.Name() + ":" + key
 }
 
-func parseCoreOSVersion(body string) (string, error) {
-	lines := strings.Split(body, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "COREOS_VERSION=") {
-			return strings.TrimPrefix(line, "COREOS_VERSION="), nil
+// releaseFeed fetches channel's releases.json-shaped feed, serving it from
+// r.cache when present so a poll cycle that checks drift across several
+// channels doesn't re-fetch the same feed it just used for a release lookup.
+func (r *releaseRepository) releaseFeed(channel string) (map[string]interface{}, error) {
+	if r.cache != nil {
+		if cached, ok := r.cache.GetFeed(r.cacheKey(channel)); ok {
+			return cached, nil
+		}
+	}
+
+	releases, err := observedGetJSON(r.client, r.distribution.ReleasesURI(channel), "releases.json")
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cache != nil {
+		if err := r.cache.PutFeed(r.cacheKey(channel), releases); err != nil {
+			logrus.WithError(err).WithField("channel", channel).Warn("Failed to persist releases feed to cache")
 		}
 	}
 
-	return "", errors.New("No CoreOS version on the page")
+	return releases, nil
 }
 
-func (r *releaseRepository) Get(release string) (*coreOSRelease, error) {
-	releases, err := GetJSON(r.client, releasesUri)
+func (r *releaseRepository) Get(channel string, release string) (*coreOSRelease, error) {
+	if r.cache != nil {
+		if cached, ok := r.cache.GetRelease(r.cacheKey(release)); ok {
+			return &cached, nil
+		}
+	}
+
+	releases, err := r.releaseFeed(channel)
 	if err != nil {
 		return nil, err
 	}
@@ -182,14 +324,24 @@ func (r *releaseRepository) Get(release string) (*coreOSRelease, error) {
 	cveIDs := parseReleaseNotes(releaseNotes)
 	var securityFixes []cve
 	var maxCVSS float64 = -1
+	var maxCVSSv3 float64 = -1
 
 	for _, cveID := range cveIDs {
 		fix := r.retrieveCVE(cveID)
 		securityFixes = append(securityFixes, fix)
 		maxCVSS = math.Max(maxCVSS, fix.CVSS)
+		maxCVSSv3 = math.Max(maxCVSSv3, fix.CVSSv3)
+	}
+
+	result := &coreOSRelease{ReleasedOn: releasedOn, ReleaseNotes: releaseNotes, SecurityFixes: securityFixes, MaxCVSS: &maxCVSS, MaxCVSSv3: &maxCVSSv3, Version: release}
+
+	if r.cache != nil {
+		if err := r.cache.PutRelease(r.cacheKey(release), *result); err != nil {
+			logrus.WithError(err).WithField("release", release).Warn("Failed to persist release to cache")
+		}
 	}
 
-	return &coreOSRelease{ReleasedOn: releasedOn, ReleaseNotes: releaseNotes, SecurityFixes: securityFixes, MaxCVSS: &maxCVSS, Version: release}, nil
+	return result, nil
 }
 
 func parseReleaseNotes(notes string) []string {
@@ -210,23 +362,29 @@ func parseReleaseNotes(notes string) []string {
 	return result
 }
 
+// retrieveCVE looks up id via the repository's on-disk cache, falling back to
+// the primary cveSource and transparently retrying against the fallback
+// source (when configured) if the primary lookup fails.
 func (r *releaseRepository) retrieveCVE(id string) cve {
-	cveResult, err := GetJSON(r.client, fmt.Sprintf(cveUri, id))
-	if err != nil {
-		return cve{err: err, ID: id}
+	if r.cache != nil {
+		if cached, ok := r.cache.GetCVE(r.cacheKey(id)); ok {
+			return cached
+		}
 	}
 
-	cvssString, ok := cveResult["cvss"].(string)
-	if !ok {
-		return cve{err: errors.New("No CVSS found!"), ID: id}
+	found, err := r.cveSource.Lookup(id)
+	if err != nil && r.cveSourceFallback != nil {
+		found, err = r.cveSourceFallback.Lookup(id)
 	}
-	cvss, err := strconv.ParseFloat(cvssString, 64)
 	if err != nil {
-		return cve{
-			err: errors.New(fmt.Sprintf("Cannot parse CVSS %s because %v", cvssString, err.Error())),
-			ID:  id,
+		return cve{err: err, ID: id}
+	}
+
+	if r.cache != nil {
+		if err := r.cache.PutCVE(r.cacheKey(id), found); err != nil {
+			logrus.WithError(err).WithField("cve", id).Warn("Failed to persist CVE to cache")
 		}
 	}
 
-	return cve{CVSS: cvss, ID: id, err: nil}
+	return found
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeDistribution points VersionURI/ReleasesURI at a test server instead of
+// the real upstream, so GetLatestVersions can be exercised without network
+// access.
+type fakeDistribution struct {
+	server *httptest.Server
+}
+
+func (d fakeDistribution) Name() string { return "fake" }
+
+func (d fakeDistribution) VersionURI(channel string) string {
+	return d.server.URL + "/version/" + channel
+}
+
+func (d fakeDistribution) ReleasesURI(channel string) string {
+	return d.server.URL + "/releases"
+}
+
+func (d fakeDistribution) ParseVersion(body string) (string, error) {
+	return parseVersionMarker(body, "FAKE_VERSION=")
+}
+
+func TestGetLatestVersionsPartialFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version/stable", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "FAKE_VERSION=1.2.3\n")
+	})
+	mux.HandleFunc("/version/beta", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "FAKE_VERSION=1.3.0\n")
+	})
+	mux.HandleFunc("/version/alpha", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/releases", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"1.2.3":{"release_notes":""},"1.3.0":{"release_notes":""}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	repo := newReleaseRepository(&http.Client{}, "", "", nil, nil, nil, fakeDistribution{server: server}, []string{"stable", "beta", "alpha"})
+
+	err := repo.GetLatestVersions()
+	if err != nil {
+		t.Fatalf("expected a nil error when at least one channel succeeds, got %v", err)
+	}
+
+	repo.RLock()
+	defer repo.RUnlock()
+
+	if got := repo.latestByChannel["stable"].Version; got != "1.2.3" {
+		t.Errorf("stable version = %q, want 1.2.3", got)
+	}
+	if got := repo.latestByChannel["beta"].Version; got != "1.3.0" {
+		t.Errorf("beta version = %q, want 1.3.0", got)
+	}
+	if _, ok := repo.latestByChannel["alpha"]; ok {
+		t.Error("expected no latestByChannel entry for the failed alpha channel")
+	}
+
+	if repo.channelErr["stable"] != nil {
+		t.Errorf("expected no channelErr for stable, got %v", repo.channelErr["stable"])
+	}
+	if repo.channelErr["alpha"] == nil {
+		t.Error("expected a channelErr for the failed alpha channel")
+	}
+}
+
+func TestGetLatestVersionsAllChannelsFail(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	repo := newReleaseRepository(&http.Client{}, "", "", nil, nil, nil, fakeDistribution{server: server}, []string{"stable", "beta"})
+
+	if err := repo.GetLatestVersions(); err == nil {
+		t.Fatal("expected an error when every channel fails")
+	}
+}